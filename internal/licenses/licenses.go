@@ -0,0 +1,159 @@
+/*
+	License - generates a human-readable file about third-party licenses
+	Copyright (C) 2024-2025  Martijn van der Kleijn
+
+	This Source Code Form is subject to the terms of the Mozilla Public
+	License, v. 2.0. If a copy of the MPL was not distributed with this
+	file, You can obtain one at http://mozilla.org/MPL/2.0/.
+*/
+
+// Package licenses identifies the SPDX id of a license whose text is known
+// but whose id isn't, by comparing normalized license text against an
+// embedded set of SPDX license templates using word-count similarity.
+package licenses
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//go:embed templates/*.txt
+var templateFS embed.FS
+
+// copyrightLineRE strips "Copyright (c) YEAR ..." lines before comparison,
+// since they're boilerplate that varies per project and isn't part of the
+// license text itself.
+var copyrightLineRE = regexp.MustCompile(`(?i)^.*copyright\s*(\(c\))?\s*(\d{4}|<year>).*$`)
+
+var whitespaceRE = regexp.MustCompile(`\s+`)
+
+// Match is the result of identifying a license against the embedded
+// template set. Score is the similarity of the best match, in [0, 1].
+type Match struct {
+	ID    string
+	Score float64
+}
+
+// Unknown is the Match.ID reported when no template meets the Scanner's
+// threshold.
+const Unknown = "UNKNOWN"
+
+// Scanner identifies SPDX licenses by comparing normalized license text
+// against an embedded set of SPDX license templates. A Scanner is built once
+// via NewScanner and is safe to share across goroutines: Identify memoizes
+// its result for each distinct license text by its SHA-256 hash, so an SBOM
+// with thousands of components carrying the same license blob scores it
+// only once.
+type Scanner struct {
+	threshold float64
+	templates map[string]map[string]int
+	cache     sync.Map // sha256 hex digest of text -> Match
+}
+
+// NewScanner builds a Scanner from the embedded SPDX license templates,
+// accepting a match only when its similarity is at or above threshold.
+func NewScanner(threshold float64) (*Scanner, error) {
+	entries, err := fs.Glob(templateFS, "templates/*.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scanner{threshold: threshold, templates: make(map[string]map[string]int, len(entries))}
+	for _, entry := range entries {
+		data, err := templateFS.ReadFile(entry)
+		if err != nil {
+			return nil, err
+		}
+		id := strings.TrimSuffix(filepath.Base(entry), ".txt")
+		s.templates[id] = wordCounts(normalize(string(data)))
+	}
+	return s, nil
+}
+
+// Identify scans text against every embedded template and returns the
+// highest-scoring match. If none meet the Scanner's threshold, Match.ID is
+// Unknown but Score still reports the best similarity found, for debugging.
+// Results are memoized by the SHA-256 of text, so identical license blobs
+// are scored only once regardless of how many components share them.
+func (s *Scanner) Identify(text string) Match {
+	digest := sha256.Sum256([]byte(text))
+	key := hex.EncodeToString(digest[:])
+
+	if cached, ok := s.cache.Load(key); ok {
+		return cached.(Match)
+	}
+
+	candidate := wordCounts(normalize(text))
+
+	var best Match
+	for id, tmpl := range s.templates {
+		score := similarity(candidate, tmpl)
+		if score > best.Score {
+			best = Match{ID: id, Score: score}
+		}
+	}
+	if best.Score < s.threshold {
+		best = Match{ID: Unknown, Score: best.Score}
+	}
+
+	s.cache.Store(key, best)
+	return best
+}
+
+// normalize lowercases text, strips copyright notice lines, collapses
+// whitespace, and tokenizes the result into words.
+func normalize(text string) []string {
+	var kept []string
+	for _, line := range strings.Split(text, "\n") {
+		if copyrightLineRE.MatchString(line) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	collapsed := whitespaceRE.ReplaceAllString(strings.Join(kept, " "), " ")
+	return strings.Fields(strings.ToLower(collapsed))
+}
+
+func wordCounts(tokens []string) map[string]int {
+	counts := make(map[string]int, len(tokens))
+	for _, t := range tokens {
+		counts[t]++
+	}
+	return counts
+}
+
+// similarity computes the Jaccard similarity of two word-count vectors: the
+// size of their multiset intersection divided by the size of their multiset
+// union.
+func similarity(a, b map[string]int) float64 {
+	var intersection, union int
+	for word, ac := range a {
+		bc := b[word]
+		if ac < bc {
+			intersection += ac
+		} else {
+			intersection += bc
+		}
+		if ac > bc {
+			union += ac
+		} else {
+			union += bc
+		}
+	}
+	for word, bc := range b {
+		if _, ok := a[word]; !ok {
+			union += bc
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(union)
+}
@@ -0,0 +1,99 @@
+/*
+	License - generates a human-readable file about third-party licenses
+	Copyright (C) 2024-2025  Martijn van der Kleijn
+
+	This Source Code Form is subject to the terms of the Mozilla Public
+	License, v. 2.0. If a copy of the MPL was not distributed with this
+	file, You can obtain one at http://mozilla.org/MPL/2.0/.
+*/
+
+package licenses
+
+import "testing"
+
+func TestSimilarityIdenticalIsOne(t *testing.T) {
+	a := wordCounts([]string{"permission", "is", "hereby", "granted"})
+	if got := similarity(a, a); got != 1 {
+		t.Errorf("similarity(a, a) = %v, want 1", got)
+	}
+}
+
+func TestSimilarityDisjointIsZero(t *testing.T) {
+	a := wordCounts([]string{"permission", "is", "hereby", "granted"})
+	b := wordCounts([]string{"redistribution", "and", "use", "in", "source"})
+	if got := similarity(a, b); got != 0 {
+		t.Errorf("similarity(a, b) = %v, want 0", got)
+	}
+}
+
+func TestSimilarityPartialOverlap(t *testing.T) {
+	a := wordCounts([]string{"the", "quick", "brown", "fox"})
+	b := wordCounts([]string{"the", "quick", "red", "fox"})
+	// intersection: the, quick, fox = 3; union: the, quick, brown, red, fox = 5
+	want := 3.0 / 5.0
+	if got := similarity(a, b); got != want {
+		t.Errorf("similarity(a, b) = %v, want %v", got, want)
+	}
+}
+
+func TestSimilarityEmptyIsZero(t *testing.T) {
+	if got := similarity(map[string]int{}, map[string]int{}); got != 0 {
+		t.Errorf("similarity({}, {}) = %v, want 0", got)
+	}
+}
+
+func TestNormalizeStripsCopyrightLines(t *testing.T) {
+	text := "Copyright (c) 2024 Jane Doe\nPermission is hereby granted."
+	got := normalize(text)
+	for _, word := range got {
+		if word == "2024" || word == "jane" || word == "doe" {
+			t.Fatalf("normalize(%q) = %v, want copyright line stripped", text, got)
+		}
+	}
+}
+
+func TestScannerIdentifyExactMatch(t *testing.T) {
+	s, err := NewScanner(0.75)
+	if err != nil {
+		t.Fatalf("NewScanner: %v", err)
+	}
+
+	mit, err := templateFS.ReadFile("templates/MIT.txt")
+	if err != nil {
+		t.Fatalf("reading embedded MIT template: %v", err)
+	}
+
+	match := s.Identify(string(mit))
+	if match.ID != "MIT" {
+		t.Errorf("Identify(MIT text) = %+v, want ID = MIT", match)
+	}
+	if match.Score != 1 {
+		t.Errorf("Identify(MIT text).Score = %v, want 1", match.Score)
+	}
+}
+
+func TestScannerIdentifyBelowThresholdIsUnknown(t *testing.T) {
+	s, err := NewScanner(0.75)
+	if err != nil {
+		t.Fatalf("NewScanner: %v", err)
+	}
+
+	match := s.Identify("this is not a license, just some unrelated prose about gardening")
+	if match.ID != Unknown {
+		t.Errorf("Identify(unrelated text) = %+v, want ID = %s", match, Unknown)
+	}
+}
+
+func TestScannerIdentifyIsMemoized(t *testing.T) {
+	s, err := NewScanner(0.75)
+	if err != nil {
+		t.Fatalf("NewScanner: %v", err)
+	}
+
+	text := "Permission is hereby granted, free of charge."
+	first := s.Identify(text)
+	second := s.Identify(text)
+	if first != second {
+		t.Errorf("Identify(text) returned different results on repeat calls: %+v vs %+v", first, second)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+	License - generates a human-readable file about third-party licenses
+	Copyright (C) 2024-2025  Martijn van der Kleijn
+
+	This Source Code Form is subject to the terms of the Mozilla Public
+	License, v. 2.0. If a copy of the MPL was not distributed with this
+	file, You can obtain one at http://mozilla.org/MPL/2.0/.
+*/
+
+// Package resolve discovers a project's dependencies without an SBOM, by
+// reading its package manifest directly: go.mod for Go, pom.xml for Maven,
+// and package.json/package-lock.json for npm.
+package resolve
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/net/html/charset"
+)
+
+// Dependency is a single resolved dependency: a name and a version, with no
+// license information. Callers run the result back through the embedded
+// license scanner (internal/licenses) to fill that in.
+type Dependency struct {
+	Name    string
+	Version string
+}
+
+// ProjectType identifies the ecosystem detected under a project root.
+type ProjectType string
+
+const (
+	Go    ProjectType = "go"
+	Maven ProjectType = "maven"
+	NPM   ProjectType = "npm"
+)
+
+// Detect inspects root for a go.mod, pom.xml, or package.json, in that
+// order, and reports which ecosystem it belongs to.
+func Detect(root string) (ProjectType, error) {
+	if fileExists(filepath.Join(root, "go.mod")) {
+		return Go, nil
+	}
+	if fileExists(filepath.Join(root, "pom.xml")) {
+		return Maven, nil
+	}
+	if fileExists(filepath.Join(root, "package.json")) {
+		return NPM, nil
+	}
+	return "", fmt.Errorf("no go.mod, pom.xml, or package.json found under %s", root)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// ResolveGo returns every dependency of the Go module rooted at root. It
+// prefers "go list -m -json all", which reports the final resolved version
+// of each module after minimal version selection, falling back to the
+// require directives in go.mod if the go tool can't run (e.g. no network or
+// module cache available).
+func ResolveGo(root string) ([]Dependency, error) {
+	if deps, err := resolveGoList(root); err == nil {
+		return deps, nil
+	}
+	return resolveGoMod(root)
+}
+
+func resolveGoList(root string) ([]Dependency, error) {
+	cmd := exec.Command("go", "list", "-m", "-json", "all")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %w", err)
+	}
+
+	var deps []Dependency
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var mod struct {
+			Path    string `json:"Path"`
+			Version string `json:"Version"`
+			Main    bool   `json:"Main"`
+		}
+		if err := dec.Decode(&mod); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if mod.Main {
+			continue
+		}
+		deps = append(deps, Dependency{Name: mod.Path, Version: mod.Version})
+	}
+	return deps, nil
+}
+
+func resolveGoMod(root string) ([]Dependency, error) {
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.Parse("go.mod", data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse go.mod: %w", err)
+	}
+
+	var deps []Dependency
+	for _, req := range f.Require {
+		deps = append(deps, Dependency{Name: req.Mod.Path, Version: req.Mod.Version})
+	}
+	return deps, nil
+}
+
+var mavenTreeLineRE = regexp.MustCompile(`([\w.\-]+):([\w.\-]+):[\w.\-]+:([\w.\-]+)`)
+
+// ResolveMaven returns every dependency declared in the Maven project rooted
+// at root. It prefers "mvn dependency:tree", which reports fully resolved
+// versions after conflict resolution, falling back to walking pom.xml's
+// <dependencies> (and any <modules>) directly if Maven isn't on PATH.
+func ResolveMaven(root string) ([]Dependency, error) {
+	if deps, err := resolveMavenTree(root); err == nil {
+		return deps, nil
+	}
+	return resolveMavenPOM(filepath.Join(root, "pom.xml"))
+}
+
+func resolveMavenTree(root string) ([]Dependency, error) {
+	cmd := exec.Command("mvn", "-q", "dependency:tree", "-DoutputType=text")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("mvn dependency:tree failed: %w", err)
+	}
+
+	var deps []Dependency
+	for _, line := range strings.Split(string(out), "\n") {
+		m := mavenTreeLineRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		deps = append(deps, Dependency{Name: m[1] + ":" + m[2], Version: m[3]})
+	}
+	return deps, nil
+}
+
+// pomDependency mirrors a <dependency> entry in a Maven POM.
+type pomDependency struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	Version    string `xml:"version"`
+}
+
+// pomProject mirrors enough of a Maven POM to walk its (possibly
+// multi-module, via <modules>) dependency declarations.
+type pomProject struct {
+	Dependencies struct {
+		Dependency []pomDependency `xml:"dependency"`
+	} `xml:"dependencies"`
+	Modules struct {
+		Module []string `xml:"module"`
+	} `xml:"modules"`
+}
+
+func resolveMavenPOM(path string) ([]Dependency, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dec := xml.NewDecoder(file)
+	dec.CharsetReader = charset.NewReaderLabel
+
+	var project pomProject
+	if err := dec.Decode(&project); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	var deps []Dependency
+	for _, d := range project.Dependencies.Dependency {
+		deps = append(deps, Dependency{Name: d.GroupID + ":" + d.ArtifactID, Version: d.Version})
+	}
+
+	dir := filepath.Dir(path)
+	for _, mod := range project.Modules.Module {
+		nested, err := resolveMavenPOM(filepath.Join(dir, mod, "pom.xml"))
+		if err != nil {
+			continue
+		}
+		deps = append(deps, nested...)
+	}
+	return deps, nil
+}
+
+// ResolveNPM returns every dependency of the npm project rooted at root. It
+// prefers package-lock.json, which records the fully resolved version of
+// every dependency in the tree, falling back to the direct ranges declared
+// in package.json if no lockfile is present.
+func ResolveNPM(root string) ([]Dependency, error) {
+	if deps, err := resolveNPMLock(filepath.Join(root, "package-lock.json")); err == nil {
+		return deps, nil
+	}
+	return resolveNPMPackageJSON(filepath.Join(root, "package.json"))
+}
+
+func resolveNPMLock(path string) ([]Dependency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lock struct {
+		Packages map[string]struct {
+			Version string `json:"version"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	var deps []Dependency
+	for pkgPath, pkg := range lock.Packages {
+		name := pkgPath
+		if idx := strings.LastIndex(pkgPath, "node_modules/"); idx != -1 {
+			name = pkgPath[idx+len("node_modules/"):]
+		}
+		if name == "" || pkg.Version == "" {
+			continue
+		}
+		deps = append(deps, Dependency{Name: name, Version: pkg.Version})
+	}
+	return deps, nil
+}
+
+func resolveNPMPackageJSON(path string) ([]Dependency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	var deps []Dependency
+	for name, version := range manifest.Dependencies {
+		deps = append(deps, Dependency{Name: name, Version: version})
+	}
+	for name, version := range manifest.DevDependencies {
+		deps = append(deps, Dependency{Name: name, Version: version})
+	}
+	return deps, nil
+}
@@ -0,0 +1,156 @@
+/*
+	License - generates a human-readable file about third-party licenses
+	Copyright (C) 2024-2025  Martijn van der Kleijn
+
+	This Source Code Form is subject to the terms of the Mozilla Public
+	License, v. 2.0. If a copy of the MPL was not distributed with this
+	file, You can obtain one at http://mozilla.org/MPL/2.0/.
+*/
+
+package resolve
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("writing %s: %v", name, err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want ProjectType
+	}{
+		{"go.mod", "go.mod", Go},
+		{"pom.xml", "pom.xml", Maven},
+		{"package.json", "package.json", NPM},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, tt.file, "")
+			got, err := Detect(dir)
+			if err != nil {
+				t.Fatalf("Detect: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Detect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectNoManifest(t *testing.T) {
+	if _, err := Detect(t.TempDir()); err == nil {
+		t.Error("Detect on empty dir: got nil error, want error")
+	}
+}
+
+func TestResolveGoMod(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "go.mod", `module example.com/foo
+
+go 1.21
+
+require (
+	golang.org/x/mod v0.17.0
+	golang.org/x/net v0.27.0
+)
+`)
+
+	deps, err := resolveGoMod(dir)
+	if err != nil {
+		t.Fatalf("resolveGoMod: %v", err)
+	}
+	if len(deps) != 2 {
+		t.Fatalf("got %d deps, want 2: %+v", len(deps), deps)
+	}
+	if deps[0].Name != "golang.org/x/mod" || deps[0].Version != "v0.17.0" {
+		t.Errorf("deps[0] = %+v, want golang.org/x/mod v0.17.0", deps[0])
+	}
+}
+
+func TestResolveNPMLockStripsNestedNodeModulesPrefix(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "package-lock.json", `{
+  "packages": {
+    "": {"version": "1.0.0"},
+    "node_modules/semver": {"version": "7.5.0"},
+    "node_modules/@babel/core/node_modules/semver": {"version": "6.3.0"}
+  }
+}`)
+
+	deps, err := resolveNPMLock(filepath.Join(dir, "package-lock.json"))
+	if err != nil {
+		t.Fatalf("resolveNPMLock: %v", err)
+	}
+
+	versions := make(map[string]bool)
+	for _, d := range deps {
+		if d.Name == "@babel/core/node_modules/semver" {
+			t.Errorf("nested dependency kept its node_modules-prefixed path as name: %+v", d)
+		}
+		if d.Name == "semver" {
+			versions[d.Version] = true
+		}
+	}
+	if !versions["7.5.0"] || !versions["6.3.0"] {
+		t.Errorf("expected both semver@7.5.0 (top-level) and semver@6.3.0 (nested) resolved to name \"semver\", got deps: %+v", deps)
+	}
+}
+
+func TestResolveNPMPackageJSON(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "package.json", `{
+  "dependencies": {"lodash": "^4.17.0"},
+  "devDependencies": {"jest": "^29.0.0"}
+}`)
+
+	deps, err := resolveNPMPackageJSON(filepath.Join(dir, "package.json"))
+	if err != nil {
+		t.Fatalf("resolveNPMPackageJSON: %v", err)
+	}
+	if len(deps) != 2 {
+		t.Fatalf("got %d deps, want 2: %+v", len(deps), deps)
+	}
+}
+
+func TestResolveMavenPOMMultiModule(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "pom.xml", `<project>
+  <modules><module>child</module></modules>
+  <dependencies>
+    <dependency><groupId>com.acme</groupId><artifactId>root-dep</artifactId><version>1.0</version></dependency>
+  </dependencies>
+</project>`)
+
+	childDir := filepath.Join(root, "child")
+	if err := os.Mkdir(childDir, 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	writeFile(t, childDir, "pom.xml", `<project>
+  <dependencies>
+    <dependency><groupId>com.acme</groupId><artifactId>child-dep</artifactId><version>2.0</version></dependency>
+  </dependencies>
+</project>`)
+
+	deps, err := resolveMavenPOM(filepath.Join(root, "pom.xml"))
+	if err != nil {
+		t.Fatalf("resolveMavenPOM: %v", err)
+	}
+
+	names := make(map[string]bool, len(deps))
+	for _, d := range deps {
+		names[d.Name] = true
+	}
+	if !names["com.acme:root-dep"] || !names["com.acme:child-dep"] {
+		t.Errorf("resolveMavenPOM did not walk into <modules>: %+v", deps)
+	}
+}
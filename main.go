@@ -10,6 +10,11 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
@@ -18,10 +23,18 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sort"
+	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/mvdkleijn/licenses/internal/licenses"
+	"github.com/mvdkleijn/licenses/internal/resolve"
 )
 
 // LicenseStatus holds the status (c/i/w) and optionally a reason.
@@ -33,33 +46,222 @@ type LicenseStatus struct {
 // Compatibility is a nested map for license compatibility lookup.
 type Compatibility map[string]map[string]LicenseStatus
 
-// Component represents the structure of a component in the input.
-type Component struct {
-	Name     string `json:"name" xml:"name"`
-	Version  string `json:"version" xml:"version"`
+// ComponentLicense represents a single CycloneDX license-choice entry: either
+// a concrete license (identified by SPDX id or, failing that, a free-text
+// name) or an SPDX license expression such as "MIT OR Apache-2.0".
+type ComponentLicense struct {
+	License *struct {
+		ID   string `json:"id,omitempty" xml:"id,omitempty"`
+		Name string `json:"name,omitempty" xml:"name,omitempty"`
+	} `json:"license,omitempty" xml:"license,omitempty"`
+	Expression string `json:"expression,omitempty" xml:"expression,omitempty"`
+}
+
+// ID returns the best available identifier for this license entry: the SPDX
+// id if present, otherwise the free-text name, otherwise the expression
+// itself.
+func (cl ComponentLicense) ID() string {
+	if cl.License != nil {
+		if cl.License.ID != "" {
+			return cl.License.ID
+		}
+		if cl.License.Name != "" {
+			return cl.License.Name
+		}
+	}
+	return cl.Expression
+}
+
+// IsExpression reports whether this entry is an SPDX license expression
+// rather than a single concrete license.
+func (cl ComponentLicense) IsExpression() bool {
+	return cl.License == nil && cl.Expression != ""
+}
+
+// NewConcreteLicense builds a ComponentLicense for a single resolved SPDX id,
+// as opposed to an expression.
+func NewConcreteLicense(id string) ComponentLicense {
+	return ComponentLicense{License: &struct {
+		ID   string `json:"id,omitempty" xml:"id,omitempty"`
+		Name string `json:"name,omitempty" xml:"name,omitempty"`
+	}{ID: id}}
+}
+
+// ComponentEvidence mirrors CycloneDX's evidence field. Licenses can carry
+// the raw text of a license a scanning tool found in a component's source,
+// as opposed to a declared SPDX id; Identity.ConcludedValue can carry the
+// filesystem path a component was resolved from.
+type ComponentEvidence struct {
+	Identity *struct {
+		ConcludedValue string `json:"concludedValue" xml:"concludedValue"`
+	} `json:"identity,omitempty" xml:"identity,omitempty"`
 	Licenses []struct {
-		License struct {
-			ID string `json:"id" xml:"id"`
-		} `json:"license" xml:"license"`
-	} `json:"licenses" xml:"licenses"`
+		Text struct {
+			Content string `json:"content" xml:"content"`
+		} `json:"text" xml:"text"`
+	} `json:"licenses" xml:"licenses>license"`
+}
+
+// ComponentProperty is a CycloneDX name/value property attached to a
+// component.
+type ComponentProperty struct {
+	Name  string `json:"name" xml:"name"`
+	Value string `json:"value" xml:"value"`
+}
+
+// Component represents the structure of a component in the input. Licenses
+// preserves every declared license entry rather than just the first one, and
+// Components holds any components nested directly under this one, as
+// permitted by CycloneDX 1.5+.
+type Component struct {
+	Name       string              `json:"name" xml:"name"`
+	Version    string              `json:"version" xml:"version"`
+	PURL       string              `json:"purl,omitempty" xml:"purl,omitempty"`
+	Licenses   []ComponentLicense  `json:"licenses" xml:"licenses>license"`
+	Components []Component         `json:"components" xml:"components>component"`
+	Evidence   *ComponentEvidence  `json:"evidence,omitempty" xml:"evidence,omitempty"`
+	Properties []ComponentProperty `json:"properties,omitempty" xml:"properties>property"`
+}
+
+// flattenComponents walks a component tree (as produced by CycloneDX's
+// nested "components" field) and returns every component in it, including
+// the roots themselves, as a single flat slice.
+func flattenComponents(components []Component) []Component {
+	var flat []Component
+	for _, c := range components {
+		nested := c.Components
+		c.Components = nil
+		flat = append(flat, c)
+		flat = append(flat, flattenComponents(nested)...)
+	}
+	return flat
+}
+
+// ComponentExclude matches components to skip entirely during validation, by
+// name and (optionally) version. Both fields are filepath.Match patterns, so
+// version: "*" (or an omitted version) excludes every version of name.
+type ComponentExclude struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version,omitempty"`
+}
+
+// Policy is a named, path-scoped compatibility ruleset. Components whose
+// source path matches Root are validated against Compatibility, except those
+// matching an entry in Excludes, which are skipped.
+type Policy struct {
+	Name          string             `yaml:"name,omitempty"`
+	Root          string             `yaml:"root"`
+	Compatibility Compatibility      `yaml:"compatibility"`
+	Excludes      []ComponentExclude `yaml:"excludes,omitempty"`
+}
+
+// Key returns the identifier a Policy is reported under: its Name if set,
+// otherwise its Root glob.
+func (p Policy) Key() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Root
 }
 
-// LoadCompatibility reads the YAML file at the given path and unmarshals it
-// into the Compatibility structure.
-func LoadCompatibility(filePath string) (Compatibility, error) {
+// LoadPolicies reads the YAML file at the given path and unmarshals it into
+// a list of Policy values. It supports both the current "policies:" list
+// format and the legacy format, a bare Compatibility matrix with no root
+// scoping, which is wrapped into a single catch-all Policy matching every
+// path.
+func LoadPolicies(filePath string) ([]Policy, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %q: %w", filePath, err)
 	}
 
+	var doc struct {
+		Policies []Policy `yaml:"policies"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+	}
+	if len(doc.Policies) > 0 {
+		return doc.Policies, nil
+	}
+
 	var comp Compatibility
 	if err := yaml.Unmarshal(data, &comp); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
 	}
+	return []Policy{{Root: "**", Compatibility: comp}}, nil
+}
 
-	return comp, nil
+// matchRoot reports whether path falls under a Policy's Root glob. A root
+// ending in "/**" matches every path below that directory, since
+// filepath.Match's "*" does not cross path separators on its own.
+func matchRoot(root, path string) bool {
+	if strings.HasSuffix(root, "/**") {
+		return strings.HasPrefix(path, strings.TrimSuffix(root, "**"))
+	}
+	if root == "**" {
+		return true
+	}
+	ok, _ := filepath.Match(root, path)
+	return ok
 }
 
+// policyFor returns the first policy whose Root matches path. If path is
+// empty (the SBOM recorded no source location for the component) or no
+// policy matches, the last policy in the list is used as the catch-all
+// default.
+func policyFor(policies []Policy, path string) (Policy, bool) {
+	if path != "" {
+		for _, p := range policies {
+			if matchRoot(p.Root, path) {
+				return p, true
+			}
+		}
+	}
+	if len(policies) > 0 {
+		return policies[len(policies)-1], true
+	}
+	return Policy{}, false
+}
+
+// isExcluded reports whether component matches any of excludes. Both name
+// and version are filepath.Match patterns; an exclude with no version
+// matches every version of that name.
+func isExcluded(excludes []ComponentExclude, component Component) bool {
+	for _, ex := range excludes {
+		if ok, _ := filepath.Match(ex.Name, component.Name); !ok {
+			continue
+		}
+		if ex.Version == "" {
+			return true
+		}
+		if ok, _ := filepath.Match(ex.Version, component.Version); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// componentSourcePath returns the filesystem path a component was sourced
+// from, as recorded by an SBOM generator via evidence.identity.concludedValue
+// or a "licenses:source-path" property. It returns "" when neither is
+// present, in which case policyFor falls back to the catch-all policy.
+func componentSourcePath(component Component) string {
+	if component.Evidence != nil && component.Evidence.Identity != nil && component.Evidence.Identity.ConcludedValue != "" {
+		return component.Evidence.Identity.ConcludedValue
+	}
+	for _, p := range component.Properties {
+		if p.Name == sourcePathPropertyName {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// sourcePathPropertyName is the CycloneDX component property this tool reads
+// a component's source path from, when no evidence.identity is present.
+const sourcePathPropertyName = "licenses:source-path"
+
 // GetLicenseStatus looks up a main license and sub license in the Compatibility map.
 // It returns the status (c/i/w), the optional reason, or an error if not found.
 func GetLicenseStatus(comp Compatibility, mainLicense, subLicense string) (string, string, error) {
@@ -78,25 +280,342 @@ func GetLicenseStatus(comp Compatibility, mainLicense, subLicense string) (strin
 	return ls.Status, ls.Reason, nil
 }
 
+// GetLicenseStatusForExpression evaluates an SPDX license expression (e.g.
+// "MIT OR Apache-2.0") against the compatibility matrix: an OR is compatible
+// if any operand is compatible, an AND requires every operand to be
+// compatible. The status and reason of the satisfying operand is returned
+// (or of the first failing operand, if none are satisfying).
+func GetLicenseStatusForExpression(comp Compatibility, mainLicense, expression string) (string, string, error) {
+	node, err := parseLicenseExpression(expression)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse license expression %q: %w", expression, err)
+	}
+	return evalLicenseExprNode(comp, mainLicense, node)
+}
+
+// licenseExprNode is a node in the small AST used to evaluate SPDX license
+// expressions. A leaf has op == "" and carries a single SPDX id; any other
+// node combines its children with "AND" or "OR".
+type licenseExprNode struct {
+	op       string
+	id       string
+	children []*licenseExprNode
+}
+
+func evalLicenseExprNode(comp Compatibility, mainLicense string, node *licenseExprNode) (string, string, error) {
+	if node.op == "" {
+		return GetLicenseStatus(comp, mainLicense, node.id)
+	}
+
+	var firstStatus, firstReason string
+	var firstErr error
+	for i, child := range node.children {
+		status, reason, err := evalLicenseExprNode(comp, mainLicense, child)
+		if i == 0 {
+			firstStatus, firstReason, firstErr = status, reason, err
+		}
+		if node.op == "OR" && err == nil && status == "c" {
+			return status, reason, nil
+		}
+		if node.op == "AND" && (err != nil || status != "c") {
+			return status, reason, err
+		}
+	}
+	if node.op == "AND" {
+		return "c", "", nil
+	}
+	return firstStatus, firstReason, firstErr
+}
+
+// tokenizeExpression splits an SPDX license expression into identifier,
+// "AND", "OR" and parenthesis tokens.
+func tokenizeExpression(expr string) []string {
+	var tokens []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range expr {
+		switch r {
+		case '(', ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case ' ', '\t', '\n':
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// exprParser is a small recursive-descent parser for SPDX license
+// expressions, giving OR lower precedence than AND and supporting
+// parenthesized groups.
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func parseLicenseExpression(expr string) (*licenseExprNode, error) {
+	p := &exprParser{tokens: tokenizeExpression(expr)}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q", p.tokens[p.pos])
+	}
+	return node, nil
+}
+
+func (p *exprParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) parseOr() (*licenseExprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "OR" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if left.op == "OR" {
+			left.children = append(left.children, right)
+		} else {
+			left = &licenseExprNode{op: "OR", children: []*licenseExprNode{left, right}}
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (*licenseExprNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "AND" {
+		p.pos++
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if left.op == "AND" {
+			left.children = append(left.children, right)
+		} else {
+			left = &licenseExprNode{op: "AND", children: []*licenseExprNode{left, right}}
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parsePrimary() (*licenseExprNode, error) {
+	tok := p.peek()
+	if tok == "" {
+		return nil, errors.New("unexpected end of license expression")
+	}
+	if tok == "(" {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("missing closing paren")
+		}
+		p.pos++
+		return node, nil
+	}
+	p.pos++
+	return &licenseExprNode{id: tok}, nil
+}
+
+// Dependency represents one entry in CycloneDX's top-level dependency graph,
+// linking a component's bom-ref to the bom-refs it depends on.
+type Dependency struct {
+	Ref       string   `json:"ref" xml:"ref,attr"`
+	DependsOn []string `json:"dependsOn" xml:"dependency>ref"`
+}
+
+// BOMMetadata mirrors CycloneDX's "metadata" object, which nests the
+// document's own component (the application being described) one level
+// deeper than the BOM itself, under "component" in both JSON and XML.
+type BOMMetadata struct {
+	Component Component `json:"component" xml:"component"`
+}
+
 // BOM represents the overall structure of the input.
 type BOM struct {
-	XMLName    xml.Name    `xml:"bom"` // Matches the root element, e.g., <bom>
-	Metadata   Component   `json:"metadata" xml:"metadata>component"`
-	Components []Component `json:"components" xml:"components>component"`
+	XMLName      xml.Name     `xml:"bom"` // Matches the root element, e.g., <bom>
+	Metadata     BOMMetadata  `json:"metadata" xml:"metadata"`
+	Components   []Component  `json:"components" xml:"components>component"`
+	Dependencies []Dependency `json:"dependencies" xml:"dependencies>dependency"`
 }
 
 // ComponentsByLicense groups components by license ID
 type ComponentsByLicense map[string][]Component
 
+// ComponentResult is the flattened, per-component outcome of a scan: its
+// resolved license and, when -validate is set, the compatibility status and
+// reason for that license. It's the shape emitted by every non-template
+// output format.
+type ComponentResult struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	PURL    string `json:"purl,omitempty"`
+	License string `json:"license"`
+	Status  string `json:"status,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// writeJSON emits results as a JSON array, one object per component.
+func writeJSON(w io.Writer, results []ComponentResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// writeCSV emits results as CSV with a stable header, suitable for piping
+// into spreadsheets or CI diff-checks.
+func writeCSV(w io.Writer, results []ComponentResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"component", "version", "license", "status", "reason"}); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if err := cw.Write([]string{r.Name, r.Version, r.License, r.Status, r.Reason}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeSPDXTagValue emits a minimal SPDX 2.3 tag-value document, one
+// Package per component, with PackageLicenseConcluded and
+// PackageLicenseDeclared set to the resolved license, or NOASSERTION when
+// there is none. License values are written as resolved, including UNKNOWN
+// and free-text license names; they are not checked against the SPDX
+// license expression syntax.
+func writeSPDXTagValue(w io.Writer, results []ComponentResult) error {
+	var b strings.Builder
+	b.WriteString("SPDXVersion: SPDX-2.3\n")
+	b.WriteString("DataLicense: CC0-1.0\n")
+	b.WriteString("SPDXID: SPDXRef-DOCUMENT\n")
+	b.WriteString("DocumentName: licenses-report\n")
+	b.WriteString("DocumentNamespace: https://spdx.org/spdxdocs/licenses-report\n")
+	b.WriteString("Creator: Tool: licenses\n")
+	b.WriteString(fmt.Sprintf("Created: %s\n", time.Now().UTC().Format(time.RFC3339)))
+
+	for i, r := range results {
+		license := r.License
+		if license == "" {
+			license = "NOASSERTION"
+		}
+		b.WriteString(fmt.Sprintf("\nPackageName: %s\n", r.Name))
+		b.WriteString(fmt.Sprintf("SPDXID: SPDXRef-Package-%d\n", i))
+		b.WriteString(fmt.Sprintf("PackageVersion: %s\n", r.Version))
+		b.WriteString("PackageDownloadLocation: NOASSERTION\n")
+		b.WriteString(fmt.Sprintf("PackageLicenseConcluded: %s\n", license))
+		b.WriteString(fmt.Sprintf("PackageLicenseDeclared: %s\n", license))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// docType identifies the kind of SBOM/license document parse has detected.
+type docType string
+
+const (
+	docCycloneDXJSON docType = "cyclonedx-json"
+	docCycloneDXXML  docType = "cyclonedx-xml"
+	docSPDXJSON      docType = "spdx-json"
+	docSPDXTagValue  docType = "spdx-tagvalue"
+)
+
+// detectDocType sniffs the content of filename to determine which kind of
+// document it is, independent of the -f flag. XML content is taken as
+// CycloneDX XML, and JSON content with neither CycloneDX nor SPDX keys as
+// CycloneDX JSON. It returns "" (meaning: defer to the caller-supplied
+// format) only when the content is neither XML, JSON, nor SPDX tag-value.
+func detectDocType(filename string) (docType, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		return docCycloneDXXML, nil
+	}
+
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		var probe struct {
+			BomFormat    string `json:"bomFormat"`
+			SpdxVersion  string `json:"spdxVersion"`
+			SpecVersion  string `json:"specVersion"`
+			DataLicense  string `json:"dataLicense"`
+			DocumentName string `json:"name"`
+		}
+		if err := json.Unmarshal(trimmed, &probe); err == nil {
+			switch {
+			case probe.BomFormat == "CycloneDX" || probe.SpecVersion != "":
+				return docCycloneDXJSON, nil
+			case probe.SpdxVersion != "" || probe.DataLicense != "":
+				return docSPDXJSON, nil
+			}
+		}
+		return docCycloneDXJSON, nil
+	}
+
+	if bytes.Contains(trimmed, []byte("SPDXVersion:")) {
+		return docSPDXTagValue, nil
+	}
+
+	return "", nil
+}
+
 func parse(filename string, format string) (BOM, error) {
 	var bom BOM
 	var err error
 
-	if format == "json" {
+	detected, derr := detectDocType(filename)
+	if derr != nil {
+		return BOM{}, derr
+	}
+	if detected == "" {
+		switch format {
+		case "json":
+			detected = docCycloneDXJSON
+		case "xml":
+			detected = docCycloneDXXML
+		default:
+			return BOM{}, fmt.Errorf("unsupported format: %s", format)
+		}
+	}
+
+	switch detected {
+	case docCycloneDXJSON:
 		bom, err = parseJSON(filename)
-	} else if format == "xml" {
+	case docCycloneDXXML:
 		bom, err = parseXML(filename)
-	} else {
+	case docSPDXJSON:
+		bom, err = parseSPDXJSON(filename)
+	case docSPDXTagValue:
+		bom, err = parseSPDXTagValue(filename)
+	default:
 		return BOM{}, fmt.Errorf("unsupported format: %s", format)
 	}
 
@@ -107,6 +626,8 @@ func parse(filename string, format string) (BOM, error) {
 		return BOM{}, err
 	}
 
+	bom.Components = flattenComponents(bom.Components)
+
 	return bom, nil
 }
 
@@ -138,71 +659,542 @@ func parseXML(filename string) (BOM, error) {
 	return bom, nil
 }
 
+// spdxJSONPackage mirrors the fields we need from an SPDX 2.3 JSON document's
+// "packages" array.
+type spdxJSONPackage struct {
+	Name             string `json:"name"`
+	VersionInfo      string `json:"versionInfo"`
+	LicenseConcluded string `json:"licenseConcluded"`
+	LicenseDeclared  string `json:"licenseDeclared"`
+}
+
+// parseSPDXJSON reads an SPDX 2.3 JSON document and maps its packages onto
+// the BOM.Components pipeline. The license expression used is
+// licenseConcluded, falling back to licenseDeclared.
+func parseSPDXJSON(filename string) (BOM, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return BOM{}, err
+	}
+	defer file.Close()
+
+	var doc struct {
+		Packages []spdxJSONPackage `json:"packages"`
+	}
+	if err := json.NewDecoder(file).Decode(&doc); err != nil {
+		return BOM{}, err
+	}
+
+	var bom BOM
+	for _, pkg := range doc.Packages {
+		license := pkg.LicenseConcluded
+		if license == "" || license == "NOASSERTION" {
+			license = pkg.LicenseDeclared
+		}
+		component := Component{Name: pkg.Name, Version: pkg.VersionInfo}
+		if license != "" && license != "NOASSERTION" {
+			component.Licenses = []ComponentLicense{{Expression: license}}
+		}
+		bom.Components = append(bom.Components, component)
+	}
+	return bom, nil
+}
+
+// parseSPDXTagValue reads an SPDX 2.3 tag-value document. Each "PackageName"
+// line starts a new Component, populated by the PackageVersion and
+// PackageLicenseConcluded/PackageLicenseDeclared lines that follow it.
+func parseSPDXTagValue(filename string) (BOM, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return BOM{}, err
+	}
+	defer file.Close()
+
+	var bom BOM
+	var current *Component
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "PackageName":
+			if current != nil {
+				bom.Components = append(bom.Components, *current)
+			}
+			current = &Component{Name: value}
+		case "PackageVersion":
+			if current != nil {
+				current.Version = value
+			}
+		case "PackageLicenseConcluded", "PackageLicenseDeclared":
+			if current != nil && value != "" && value != "NOASSERTION" && len(current.Licenses) == 0 {
+				current.Licenses = []ComponentLicense{{Expression: value}}
+			}
+		}
+	}
+	if current != nil {
+		bom.Components = append(bom.Components, *current)
+	}
+	if err := scanner.Err(); err != nil {
+		return BOM{}, err
+	}
+
+	return bom, nil
+}
+
+// readLicenseFile looks for a LICENSE/COPYING file for the given component
+// under licensesDir/<name>@<version>/ and returns its contents.
+func readLicenseFile(licensesDir, name, version string) (string, bool) {
+	dir := filepath.Join(licensesDir, name+"@"+version)
+	for _, candidate := range []string{"LICENSE", "LICENSE.txt", "LICENSE.md", "COPYING"} {
+		data, err := os.ReadFile(filepath.Join(dir, candidate))
+		if err == nil {
+			return string(data), true
+		}
+	}
+	return "", false
+}
+
+// resolveFromSource builds a BOM directly from a project's manifest file
+// (go.mod, pom.xml, or package.json) when no SBOM was supplied via -i.
+// Resolved dependencies carry no license id; the embedded scanner fills that
+// in from -licenses-dir or SBOM evidence, same as for any other component.
+// The application's own license, which a CycloneDX/SPDX input would have
+// carried in its metadata, is identified from a LICENSE/COPYING file at root,
+// if present, so -validate has something to check dependencies against.
+func resolveFromSource(root string, scanner *licenses.Scanner) (BOM, error) {
+	projectType, err := resolve.Detect(root)
+	if err != nil {
+		return BOM{}, err
+	}
+
+	var deps []resolve.Dependency
+	switch projectType {
+	case resolve.Go:
+		deps, err = resolve.ResolveGo(root)
+	case resolve.Maven:
+		deps, err = resolve.ResolveMaven(root)
+	case resolve.NPM:
+		deps, err = resolve.ResolveNPM(root)
+	}
+	if err != nil {
+		return BOM{}, fmt.Errorf("failed to resolve dependencies under %s: %w", root, err)
+	}
+
+	var bom BOM
+	if id, ok := resolveRootLicense(scanner, root); ok {
+		bom.Metadata.Component.Licenses = []ComponentLicense{NewConcreteLicense(id)}
+	}
+	for _, d := range deps {
+		bom.Components = append(bom.Components, Component{Name: d.Name, Version: d.Version})
+	}
+	return bom, nil
+}
+
+// resolveRootLicense identifies the project's own license from a
+// LICENSE/COPYING file at its root, the same file names and matching used
+// for an individual component's -licenses-dir entry.
+func resolveRootLicense(scanner *licenses.Scanner, root string) (string, bool) {
+	for _, candidate := range []string{"LICENSE", "LICENSE.txt", "LICENSE.md", "COPYING"} {
+		data, err := os.ReadFile(filepath.Join(root, candidate))
+		if err != nil {
+			continue
+		}
+		if match := scanner.Identify(string(data)); match.ID != licenses.Unknown {
+			return match.ID, true
+		}
+	}
+	return "", false
+}
+
+// resolveComponentLicense identifies the license of a component that
+// declares no SPDX id, first from a LICENSE/COPYING file under licensesDir
+// and, failing that, from license evidence text embedded in the SBOM. It
+// returns licenses.Unknown if neither source yields a confident match.
+func resolveComponentLicense(scanner *licenses.Scanner, component Component, licensesDir string) string {
+	if licensesDir != "" {
+		if text, ok := readLicenseFile(licensesDir, component.Name, component.Version); ok {
+			if match := scanner.Identify(text); match.ID != licenses.Unknown {
+				return match.ID
+			}
+		}
+	}
+
+	if component.Evidence != nil {
+		for _, l := range component.Evidence.Licenses {
+			if l.Text.Content == "" {
+				continue
+			}
+			if match := scanner.Identify(l.Text.Content); match.ID != licenses.Unknown {
+				return match.ID
+			}
+		}
+	}
+
+	return licenses.Unknown
+}
+
+// cachedStatus is the JSON-serializable form of a cached compatibility
+// lookup: either a Status/Reason pair, or Err if the lookup failed.
+type cachedStatus struct {
+	Status string `json:"status,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Err    string `json:"err,omitempty"`
+}
+
+// persistedCache is the on-disk form of a LicenseScanner's compatibility
+// cache. PolicyFingerprint records the compatibility.yaml that produced
+// Entries, so a cache from before a policy change is detected as stale
+// instead of silently serving outdated verdicts.
+type persistedCache struct {
+	PolicyFingerprint string                  `json:"policyFingerprint,omitempty"`
+	Entries           map[string]cachedStatus `json:"entries"`
+}
+
+// LicenseScanner bundles the embedded template scanner with a compatibility-
+// lookup cache behind a single, goroutine-safe type. It's constructed once
+// and shared by every worker, so an SBOM with thousands of duplicate license
+// blobs or repeated (policy, license-pair) lookups pays the cost only once.
+type LicenseScanner struct {
+	templates         *licenses.Scanner
+	licensesDir       string
+	compatCache       sync.Map // "policy|mainLicense|subLicense" -> cachedStatus
+	policyFingerprint string   // fingerprint of the compatibility.yaml active for this run
+}
+
+// NewLicenseScanner builds a LicenseScanner and, if cacheDir is non-empty,
+// seeds its compatibility cache from a previous run's persisted cache file.
+// policyFingerprint identifies the compatibility.yaml in effect for this run
+// (see hashFile); a persisted cache whose fingerprint doesn't match is
+// treated as stale and discarded rather than seeded, since it may hold
+// verdicts for a policy that has since been tightened or loosened.
+func NewLicenseScanner(threshold float64, licensesDir, cacheDir, policyFingerprint string) (*LicenseScanner, error) {
+	templates, err := licenses.NewScanner(threshold)
+	if err != nil {
+		return nil, err
+	}
+	s := &LicenseScanner{templates: templates, licensesDir: licensesDir, policyFingerprint: policyFingerprint}
+
+	if cacheDir != "" {
+		if data, err := os.ReadFile(filepath.Join(cacheDir, "license-cache.json")); err == nil {
+			var persisted persistedCache
+			if err := json.Unmarshal(data, &persisted); err == nil {
+				switch {
+				case policyFingerprint == "":
+					// Not validating this run, so there's nothing to check the
+					// cache against; keep it as-is for a future -validate run.
+					s.policyFingerprint = persisted.PolicyFingerprint
+					fallthrough
+				case persisted.PolicyFingerprint == policyFingerprint:
+					for k, v := range persisted.Entries {
+						s.compatCache.Store(k, v)
+					}
+				}
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// Save persists the compatibility cache to cacheDir as a small JSON file
+// alongside the policy fingerprint it was computed under, so a subsequent
+// run against the same policies can skip re-evaluating license pairs it
+// already checked, while a changed compatibility.yaml starts from empty.
+func (s *LicenseScanner) Save(cacheDir string) error {
+	if cacheDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return err
+	}
+
+	entries := make(map[string]cachedStatus)
+	s.compatCache.Range(func(key, value any) bool {
+		entries[key.(string)] = value.(cachedStatus)
+		return true
+	})
+
+	data, err := json.MarshalIndent(persistedCache{PolicyFingerprint: s.policyFingerprint, Entries: entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(cacheDir, "license-cache.json"), data, 0o644)
+}
+
+// hashFile returns a hex-encoded SHA-256 fingerprint of path's contents,
+// used to detect when compatibility.yaml has changed since a compatibility
+// cache was persisted.
+func hashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// ResolveComponentLicense identifies the license of a component with no
+// declared SPDX id. Identification itself is memoized inside the embedded
+// template scanner, keyed by the SHA-256 of the candidate text.
+func (s *LicenseScanner) ResolveComponentLicense(component Component) string {
+	return resolveComponentLicense(s.templates, component, s.licensesDir)
+}
+
+// Status looks up the compatibility status of subLicense (a concrete SPDX id
+// or, if expression is true, an SPDX expression) against mainLicense under
+// the named policy, caching the result for reuse by every other component
+// that hits the same (policy, mainLicense, subLicense) triple.
+func (s *LicenseScanner) Status(comp Compatibility, policyKey, mainLicense, subLicense string, expression bool) (string, string, error) {
+	key := policyKey + "|" + mainLicense + "|" + subLicense
+	if cached, ok := s.compatCache.Load(key); ok {
+		cs := cached.(cachedStatus)
+		if cs.Err != "" {
+			return "", "", errors.New(cs.Err)
+		}
+		return cs.Status, cs.Reason, nil
+	}
+
+	var status, reason string
+	var err error
+	if expression {
+		status, reason, err = GetLicenseStatusForExpression(comp, mainLicense, subLicense)
+	} else {
+		status, reason, err = GetLicenseStatus(comp, mainLicense, subLicense)
+	}
+
+	cs := cachedStatus{Status: status, Reason: reason}
+	if err != nil {
+		cs.Err = err.Error()
+	}
+	s.compatCache.Store(key, cs)
+
+	return status, reason, err
+}
+
+// workerOutput is what processComponent hands back to the single writer
+// goroutine that merges every worker's results into the report-wide state.
+type workerOutput struct {
+	component    Component
+	licenseID    string
+	result       ComponentResult
+	excludedNote string
+	issues       map[string]map[string]LicenseStatus // policy key -> license id -> issue
+}
+
+// processComponent resolves a component's license (if not already declared)
+// and, when validate is set, checks it against the policy scoped to the
+// component's source path. Apart from the shared LicenseScanner's
+// goroutine-safe caches, it mutates nothing outside its own copy of
+// component, so many can run concurrently against a single LicenseScanner.
+func processComponent(component Component, scanner *LicenseScanner, policies []Policy, applicationLicense string, validate bool) workerOutput {
+	if len(component.Licenses) == 0 {
+		id := scanner.ResolveComponentLicense(component)
+		component.Licenses = []ComponentLicense{NewConcreteLicense(id)}
+	}
+
+	out := workerOutput{
+		component: component,
+		result:    ComponentResult{Name: component.Name, Version: component.Version, PURL: component.PURL},
+	}
+
+	out.licenseID = component.Licenses[0].ID()
+	out.result.License = out.licenseID
+
+	if !validate {
+		return out
+	}
+
+	policy, ok := policyFor(policies, componentSourcePath(component))
+	if !ok {
+		return out
+	}
+
+	if isExcluded(policy.Excludes, component) {
+		out.excludedNote = fmt.Sprintf("%s@%s (policy %s)", component.Name, component.Version, policy.Key())
+		out.result.Status = "excluded"
+		return out
+	}
+
+	out.issues = make(map[string]map[string]LicenseStatus)
+	for idx, cl := range component.Licenses {
+		id := cl.ID()
+		if id == "" {
+			continue
+		}
+
+		status, reason, err := scanner.Status(policy.Compatibility, policy.Key(), applicationLicense, id, cl.IsExpression())
+
+		if idx == 0 {
+			if err != nil {
+				out.result.Status = "error"
+				out.result.Reason = "License not found in compatibility matrix."
+			} else {
+				out.result.Status = status
+				out.result.Reason = reason
+			}
+		}
+
+		if err == nil && status != "w" && status != "i" {
+			continue
+		}
+
+		if out.issues[policy.Key()] == nil {
+			out.issues[policy.Key()] = make(map[string]LicenseStatus)
+		}
+		if err != nil {
+			out.issues[policy.Key()][id] = LicenseStatus{
+				Status: "error",
+				Reason: "ERROR - License not found in compatibility matrix.",
+			}
+		} else {
+			out.issues[policy.Key()][id] = LicenseStatus{
+				Status: status,
+				Reason: reason,
+			}
+		}
+	}
+
+	return out
+}
+
+// applicationLicenseID returns the SPDX id (or expression) of the main
+// application's declared license, as recorded in the SBOM's metadata
+// component. It returns "" when no metadata license is present, which
+// GetLicenseStatus and GetLicenseStatusForExpression treat as just another
+// unrecognized main license rather than a crash.
+func applicationLicenseID(bom BOM) string {
+	if len(bom.Metadata.Component.Licenses) == 0 {
+		return ""
+	}
+	return bom.Metadata.Component.Licenses[len(bom.Metadata.Component.Licenses)-1].ID()
+}
+
 func main() {
 	inputFileFlag := flag.String("i", "./sbom.json", "Input file path")
 	outputFileFlag := flag.String("o", "./licenses.md", "Output file.")
 	formatFlag := flag.String("f", "json", "Input format (json or xml)")
 	templateFileFlag := flag.String("t", "./template.txt", "Golang template file to use for output.")
+	outputFormatFlag := flag.String("output-format", "template", "Output format: template, json, csv, or spdx-tagvalue.")
 	validateLicenses := flag.Bool("validate", false, "Validate that dependency's licenses are compatible with the main license.")
+	licensesDirFlag := flag.String("licenses-dir", "", "Directory of per-component LICENSE files (<licenses-dir>/<name>@<version>/LICENSE) used to identify licenses for components with no declared SPDX id.")
+	licenseThresholdFlag := flag.Float64("license-threshold", 0.75, "Minimum template-match similarity required to accept a license identified from file or evidence text.")
+	sourceFlag := flag.String("source", "", "Project root to resolve dependencies from directly (go.mod, pom.xml, or package.json), used when -i is not given.")
+	jobsFlag := flag.Int("jobs", runtime.NumCPU(), "Maximum number of components to process concurrently.")
+	cacheDirFlag := flag.String("cache-dir", "", "Directory to persist the license scanner's compatibility cache across runs.")
 	flag.Parse()
 
+	inputFileGiven := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "i" {
+			inputFileGiven = true
+		}
+	})
+
 	var bom BOM
-	var comp Compatibility
+	var policies []Policy
 	var err error
+	var policyFingerprint string
 
 	if *validateLicenses {
-		comp, err = LoadCompatibility("compatibility.yaml")
+		policies, err = LoadPolicies("compatibility.yaml")
+		if err != nil {
+			log.Fatal(err)
+		}
+		policyFingerprint, err = hashFile("compatibility.yaml")
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 
-	bom, err = parse(*inputFileFlag, *formatFlag)
+	scanner, err := NewLicenseScanner(*licenseThresholdFlag, *licensesDirFlag, *cacheDirFlag, policyFingerprint)
+	if err != nil {
+		log.Fatalf("failed to build license scanner: %v", err)
+	}
+
+	if *sourceFlag != "" && !inputFileGiven {
+		bom, err = resolveFromSource(*sourceFlag, scanner.templates)
+	} else {
+		bom, err = parse(*inputFileFlag, *formatFlag)
+	}
 	if err != nil {
 		log.Fatalf("Error parsing file: %v", err)
 	}
 
-	// Retrieve main application license
-	applicationLicense := bom.Metadata.Licenses[len(bom.Metadata.Licenses)-1]
-	compissues := make(map[string]LicenseStatus)
+	// Retrieve main application license. Metadata is populated only by the
+	// CycloneDX parsers and, from a root LICENSE/COPYING file, by -source
+	// resolution; the SPDX parsers leave it empty, so it may be absent.
+	applicationLicense := applicationLicenseID(bom)
 
 	if len(bom.Components) == 0 {
 		log.Fatalf("unknown structure or empty components in sbom")
 	}
 
-	// Group components by license
-	componentsByLicense := make(ComponentsByLicense)
-	for _, component := range bom.Components {
-		licenseID := "No License"
-		if len(component.Licenses) > 0 {
-			licenseID = component.Licenses[0].License.ID
-			if *validateLicenses {
-				status, reason, err := GetLicenseStatus(comp, applicationLicense.License.ID, licenseID)
-				if err != nil {
-					compissues[component.Licenses[0].License.ID] = LicenseStatus{
-						Status: "error",
-						Reason: "ERROR - License not found in compatibility matrix.",
-					}
-				} else if status == "w" || status == "i" {
-					compissues[component.Licenses[0].License.ID] = LicenseStatus{
-						Status: status,
-						Reason: reason,
-					}
-				}
+	// Fan license resolution and compatibility lookups for every component
+	// out across a worker pool, capped at -jobs, funneling results back
+	// through outputs for a single goroutine to merge.
+	jobs := *jobsFlag
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	type job struct {
+		index     int
+		component Component
+	}
+
+	jobCh := make(chan job)
+	outputs := make([]workerOutput, len(bom.Components))
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobCh {
+				outputs[j.index] = processComponent(j.component, scanner, policies, applicationLicense, *validateLicenses)
 			}
-		}
-		componentsByLicense[licenseID] = append(componentsByLicense[licenseID], component)
+		}()
 	}
+	for i, component := range bom.Components {
+		jobCh <- job{index: i, component: component}
+	}
+	close(jobCh)
+	wg.Wait()
 
-	var licenseKeys []string
-	for license := range componentsByLicense {
-		licenseKeys = append(licenseKeys, license)
+	if err := scanner.Save(*cacheDirFlag); err != nil {
+		log.Fatalf("failed to persist license cache: %v", err)
 	}
-	sort.Strings(licenseKeys)
 
-	tmpl, err := template.ParseFiles(*templateFileFlag)
-	if err != nil {
-		log.Fatalf("failed to parse template file: %v", err)
+	// Merge every worker's output into the report-wide state. This is the
+	// single writer the worker pool funnels into, so no further locking is
+	// needed here.
+	compissues := make(map[string]map[string]LicenseStatus) // policy key -> license id -> issue
+	var excluded []string
+	componentsByLicense := make(ComponentsByLicense)
+	var results []ComponentResult
+
+	for _, out := range outputs {
+		componentsByLicense[out.licenseID] = append(componentsByLicense[out.licenseID], out.component)
+		results = append(results, out.result)
+		if out.excludedNote != "" {
+			excluded = append(excluded, out.excludedNote)
+		}
+		for policyKey, issues := range out.issues {
+			if compissues[policyKey] == nil {
+				compissues[policyKey] = make(map[string]LicenseStatus)
+			}
+			for id, status := range issues {
+				compissues[policyKey][id] = status
+			}
+		}
 	}
 
 	outputFile, err := os.Create(*outputFileFlag)
@@ -211,26 +1203,64 @@ func main() {
 	}
 	defer outputFile.Close()
 
-	// Prepare data for the template
-	data := struct {
-		SortedKeys          []string
-		ComponentsByLicense ComponentsByLicense
-	}{
-		SortedKeys:          licenseKeys,
-		ComponentsByLicense: componentsByLicense,
-	}
+	switch *outputFormatFlag {
+	case "template":
+		var licenseKeys []string
+		for license := range componentsByLicense {
+			licenseKeys = append(licenseKeys, license)
+		}
+		sort.Strings(licenseKeys)
+
+		tmpl, err := template.ParseFiles(*templateFileFlag)
+		if err != nil {
+			log.Fatalf("failed to parse template file: %v", err)
+		}
 
-	if err := tmpl.Execute(outputFile, data); err != nil {
-		log.Fatalf("failed to execute template: %v", err)
+		// Prepare data for the template
+		data := struct {
+			SortedKeys          []string
+			ComponentsByLicense ComponentsByLicense
+		}{
+			SortedKeys:          licenseKeys,
+			ComponentsByLicense: componentsByLicense,
+		}
+
+		if err := tmpl.Execute(outputFile, data); err != nil {
+			log.Fatalf("failed to execute template: %v", err)
+		}
+	case "json":
+		if err := writeJSON(outputFile, results); err != nil {
+			log.Fatalf("failed to write JSON output: %v", err)
+		}
+	case "csv":
+		if err := writeCSV(outputFile, results); err != nil {
+			log.Fatalf("failed to write CSV output: %v", err)
+		}
+	case "spdx-tagvalue":
+		if err := writeSPDXTagValue(outputFile, results); err != nil {
+			log.Fatalf("failed to write SPDX output: %v", err)
+		}
+	default:
+		log.Fatalf("unsupported output format: %s", *outputFormatFlag)
 	}
 
 	fmt.Printf("Components information has been written to %s\n", *outputFileFlag)
 
+	if *validateLicenses && len(excluded) > 0 {
+		fmt.Println("Excluded components (skipped by policy):")
+		for _, note := range excluded {
+			fmt.Printf("- %s\n", note)
+		}
+	}
+
 	// If there are any issues, exit with non-zero status
 	if *validateLicenses && len(compissues) > 0 {
 		fmt.Println("Found license compatibility issues:")
-		for key, issue := range compissues {
-			fmt.Printf("- %s: %s)\n", key, issue.Reason)
+		for policyKey, issues := range compissues {
+			fmt.Printf("Policy %s:\n", policyKey)
+			for key, issue := range issues {
+				fmt.Printf("- %s: %s)\n", key, issue.Reason)
+			}
 		}
 		os.Exit(1) // Non-zero exit code for build pipeline
 	}
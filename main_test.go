@@ -0,0 +1,558 @@
+/*
+	License - generates a human-readable file about third-party licenses
+	Copyright (C) 2024-2025  Martijn van der Kleijn
+
+	This Source Code Form is subject to the terms of the Mozilla Public
+	License, v. 2.0. If a copy of the MPL was not distributed with this
+	file, You can obtain one at http://mozilla.org/MPL/2.0/.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// cyclonedxJSONFixture is a realistic CycloneDX 1.5 JSON document, as
+// produced by tools like Syft/Trivy/cdxgen, with a populated
+// metadata.component.licenses.
+const cyclonedxJSONFixture = `{
+  "bomFormat": "CycloneDX",
+  "specVersion": "1.5",
+  "metadata": {
+    "component": {
+      "name": "myapp",
+      "version": "1.0.0",
+      "licenses": [{"license": {"id": "Apache-2.0"}}]
+    }
+  },
+  "components": [
+    {"name": "foo", "version": "1.0.0", "licenses": [{"license": {"id": "MIT"}}]},
+    {"name": "bar", "version": "2.0.0", "licenses": [{"license": {"id": "GPL-3.0"}}]}
+  ]
+}`
+
+func TestParseJSONPopulatesMetadataComponentLicense(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sbom.json")
+	if err := os.WriteFile(path, []byte(cyclonedxJSONFixture), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	bom, err := parse(path, "json")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if got := applicationLicenseID(bom); got != "Apache-2.0" {
+		t.Errorf("applicationLicenseID(bom) = %q, want %q", got, "Apache-2.0")
+	}
+}
+
+// TestValidatePipelineEndToEnd runs a CycloneDX JSON fixture through parse,
+// applicationLicenseID and processComponent together, the same sequence
+// main runs under -validate, to guard against the main license silently
+// resolving to "" (and every component then failing validation) because of
+// a BOM struct tag mismatch.
+func TestValidatePipelineEndToEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sbom.json")
+	if err := os.WriteFile(path, []byte(cyclonedxJSONFixture), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	bom, err := parse(path, "json")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	applicationLicense := applicationLicenseID(bom)
+	if applicationLicense != "Apache-2.0" {
+		t.Fatalf("applicationLicenseID(bom) = %q, want %q", applicationLicense, "Apache-2.0")
+	}
+
+	policies := []Policy{{Root: "**", Compatibility: Compatibility{
+		"Apache-2.0": {
+			"MIT":     LicenseStatus{Status: "c"},
+			"GPL-3.0": LicenseStatus{Status: "i", Reason: "copyleft"},
+		},
+	}}}
+
+	scanner, err := NewLicenseScanner(0.75, "", "", "")
+	if err != nil {
+		t.Fatalf("NewLicenseScanner: %v", err)
+	}
+
+	var results []ComponentResult
+	for _, c := range bom.Components {
+		results = append(results, processComponent(c, scanner, policies, applicationLicense, true).result)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if got := results[0]; got.Name != "foo" || got.Status != "c" {
+		t.Errorf("foo result = %+v, want status c", got)
+	}
+	if got := results[1]; got.Name != "bar" || got.Status != "i" || got.Reason != "copyleft" {
+		t.Errorf("bar result = %+v, want status i, reason copyleft", got)
+	}
+}
+
+func TestComponentLicenseID(t *testing.T) {
+	tests := []struct {
+		name string
+		cl   ComponentLicense
+		want string
+	}{
+		{"concrete id", NewConcreteLicense("MIT"), "MIT"},
+		{"expression", ComponentLicense{Expression: "MIT OR Apache-2.0"}, "MIT OR Apache-2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cl.ID(); got != tt.want {
+				t.Errorf("ID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComponentLicenseIsExpression(t *testing.T) {
+	if NewConcreteLicense("MIT").IsExpression() {
+		t.Error("NewConcreteLicense(...).IsExpression() = true, want false")
+	}
+	if !(ComponentLicense{Expression: "MIT OR Apache-2.0"}).IsExpression() {
+		t.Error("expression ComponentLicense.IsExpression() = false, want true")
+	}
+}
+
+func TestParseLicenseExpression(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"MIT", false},
+		{"MIT OR Apache-2.0", false},
+		{"MIT AND Apache-2.0", false},
+		{"(MIT OR Apache-2.0) AND BSD-3-Clause", false},
+		{"MIT OR (Apache-2.0 AND BSD-3-Clause)", false},
+		{"MIT AND", true},
+		{"(MIT OR Apache-2.0", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := parseLicenseExpression(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseLicenseExpression(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetLicenseStatusForExpressionOR(t *testing.T) {
+	comp := Compatibility{
+		"Apache-2.0": {
+			"MIT":        LicenseStatus{Status: "i", Reason: "incompatible"},
+			"Apache-2.0": LicenseStatus{Status: "c", Reason: "same license"},
+		},
+	}
+
+	status, reason, err := GetLicenseStatusForExpression(comp, "Apache-2.0", "MIT OR Apache-2.0")
+	if err != nil {
+		t.Fatalf("GetLicenseStatusForExpression: %v", err)
+	}
+	if status != "c" || reason != "same license" {
+		t.Errorf("got status=%q reason=%q, want status=c reason=%q", status, reason, "same license")
+	}
+}
+
+func TestGetLicenseStatusForExpressionAND(t *testing.T) {
+	comp := Compatibility{
+		"Apache-2.0": {
+			"MIT":        LicenseStatus{Status: "c"},
+			"Apache-2.0": LicenseStatus{Status: "i", Reason: "copyleft clash"},
+		},
+	}
+
+	status, reason, err := GetLicenseStatusForExpression(comp, "Apache-2.0", "MIT AND Apache-2.0")
+	if err != nil {
+		t.Fatalf("GetLicenseStatusForExpression: %v", err)
+	}
+	if status != "i" || reason != "copyleft clash" {
+		t.Errorf("got status=%q reason=%q, want the failing AND operand", status, reason)
+	}
+}
+
+func TestGetLicenseStatusForExpressionAllCompatibleAND(t *testing.T) {
+	comp := Compatibility{
+		"Apache-2.0": {
+			"MIT":        LicenseStatus{Status: "c"},
+			"Apache-2.0": LicenseStatus{Status: "c"},
+		},
+	}
+
+	status, _, err := GetLicenseStatusForExpression(comp, "Apache-2.0", "MIT AND Apache-2.0")
+	if err != nil {
+		t.Fatalf("GetLicenseStatusForExpression: %v", err)
+	}
+	if status != "c" {
+		t.Errorf("status = %q, want c", status)
+	}
+}
+
+func TestGetLicenseStatusForExpressionUnknownLicense(t *testing.T) {
+	comp := Compatibility{}
+	if _, _, err := GetLicenseStatusForExpression(comp, "Apache-2.0", "GPL-3.0"); err == nil {
+		t.Error("GetLicenseStatusForExpression with no matching entry: got nil error, want error")
+	}
+}
+
+func TestGetLicenseStatusForExpressionMalformed(t *testing.T) {
+	comp := Compatibility{}
+	if _, _, err := GetLicenseStatusForExpression(comp, "Apache-2.0", "MIT AND"); err == nil {
+		t.Error("GetLicenseStatusForExpression with malformed expression: got nil error, want error")
+	}
+}
+
+func TestMatchRoot(t *testing.T) {
+	tests := []struct {
+		root, path string
+		want       bool
+	}{
+		{"**", "anything/at/all", true},
+		{"vendor/**", "vendor/acme/widget", true},
+		{"vendor/**", "vendor", false},
+		{"internal/*", "internal/foo", true},
+		{"internal/*", "internal/foo/bar", false},
+		{"internal/*", "other/foo", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.root+" "+tt.path, func(t *testing.T) {
+			if got := matchRoot(tt.root, tt.path); got != tt.want {
+				t.Errorf("matchRoot(%q, %q) = %v, want %v", tt.root, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyForMatchesRootBeforeFallback(t *testing.T) {
+	policies := []Policy{
+		{Name: "vendor", Root: "vendor/**"},
+		{Name: "default", Root: "**"},
+	}
+
+	p, ok := policyFor(policies, "vendor/acme/widget")
+	if !ok || p.Key() != "vendor" {
+		t.Errorf("policyFor(vendor path) = %+v, ok=%v, want policy \"vendor\"", p, ok)
+	}
+
+	p, ok = policyFor(policies, "src/app")
+	if !ok || p.Key() != "default" {
+		t.Errorf("policyFor(unmatched path) = %+v, ok=%v, want fallback policy \"default\"", p, ok)
+	}
+}
+
+func TestPolicyForEmptyPathUsesFallback(t *testing.T) {
+	policies := []Policy{
+		{Name: "vendor", Root: "vendor/**"},
+		{Name: "default", Root: "**"},
+	}
+
+	p, ok := policyFor(policies, "")
+	if !ok || p.Key() != "default" {
+		t.Errorf("policyFor(\"\") = %+v, ok=%v, want fallback policy \"default\"", p, ok)
+	}
+}
+
+func TestPolicyForNoPolicies(t *testing.T) {
+	if _, ok := policyFor(nil, "src/app"); ok {
+		t.Error("policyFor(nil, ...) ok = true, want false")
+	}
+}
+
+func TestPolicyKeyFallsBackToRoot(t *testing.T) {
+	p := Policy{Root: "vendor/**"}
+	if got := p.Key(); got != "vendor/**" {
+		t.Errorf("Key() = %q, want %q", got, "vendor/**")
+	}
+}
+
+func TestIsExcluded(t *testing.T) {
+	excludes := []ComponentExclude{
+		{Name: "left-pad", Version: "*"},
+		{Name: "acme-*", Version: "1.2.3"},
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		want    bool
+	}{
+		{"left-pad", "1.0.0", true},
+		{"left-pad", "2.0.0", true},
+		{"acme-widget", "1.2.3", true},
+		{"acme-widget", "1.2.4", false},
+		{"other", "1.0.0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"@"+tt.version, func(t *testing.T) {
+			c := Component{Name: tt.name, Version: tt.version}
+			if got := isExcluded(excludes, c); got != tt.want {
+				t.Errorf("isExcluded(%s@%s) = %v, want %v", tt.name, tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComponentSourcePathFromEvidence(t *testing.T) {
+	c := Component{
+		Evidence: &ComponentEvidence{
+			Identity: &struct {
+				ConcludedValue string `json:"concludedValue" xml:"concludedValue"`
+			}{ConcludedValue: "vendor/acme/widget"},
+		},
+	}
+	if got := componentSourcePath(c); got != "vendor/acme/widget" {
+		t.Errorf("componentSourcePath = %q, want %q", got, "vendor/acme/widget")
+	}
+}
+
+func TestComponentSourcePathFromProperty(t *testing.T) {
+	c := Component{
+		Properties: []ComponentProperty{{Name: sourcePathPropertyName, Value: "src/app"}},
+	}
+	if got := componentSourcePath(c); got != "src/app" {
+		t.Errorf("componentSourcePath = %q, want %q", got, "src/app")
+	}
+}
+
+func TestComponentSourcePathAbsent(t *testing.T) {
+	if got := componentSourcePath(Component{}); got != "" {
+		t.Errorf("componentSourcePath(Component{}) = %q, want \"\"", got)
+	}
+}
+
+var sampleResults = []ComponentResult{
+	{Name: "foo", Version: "1.0.0", License: "MIT", Status: "c"},
+	{Name: "bar", Version: "2.0.0", License: "GPL-3.0", Status: "i", Reason: "copyleft"},
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, sampleResults); err != nil {
+		t.Fatalf("writeJSON: %v", err)
+	}
+
+	var got []ComponentResult
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshaling writeJSON output: %v", err)
+	}
+	if len(got) != len(sampleResults) || got[0] != sampleResults[0] || got[1] != sampleResults[1] {
+		t.Errorf("writeJSON round-trip = %+v, want %+v", got, sampleResults)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, sampleResults); err != nil {
+		t.Fatalf("writeCSV: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3 (header + 2 rows): %q", len(lines), buf.String())
+	}
+	if lines[0] != "component,version,license,status,reason" {
+		t.Errorf("header = %q, want the stable column order", lines[0])
+	}
+	if lines[2] != "bar,2.0.0,GPL-3.0,i,copyleft" {
+		t.Errorf("second row = %q, want %q", lines[2], "bar,2.0.0,GPL-3.0,i,copyleft")
+	}
+}
+
+func TestWriteSPDXTagValue(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeSPDXTagValue(&buf, sampleResults); err != nil {
+		t.Fatalf("writeSPDXTagValue: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"SPDXVersion: SPDX-2.3",
+		"PackageName: foo",
+		"PackageLicenseConcluded: MIT",
+		"PackageName: bar",
+		"PackageLicenseConcluded: GPL-3.0",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("writeSPDXTagValue output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteSPDXTagValueNoAssertionForMissingLicense(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeSPDXTagValue(&buf, []ComponentResult{{Name: "baz", Version: "1.0.0"}}); err != nil {
+		t.Fatalf("writeSPDXTagValue: %v", err)
+	}
+	if !strings.Contains(buf.String(), "PackageLicenseConcluded: NOASSERTION") {
+		t.Errorf("writeSPDXTagValue with no license did not fall back to NOASSERTION:\n%s", buf.String())
+	}
+}
+
+var testCompatibility = Compatibility{
+	"Apache-2.0": {"MIT": LicenseStatus{Status: "c"}},
+}
+
+func TestLicenseScannerStatusIsCached(t *testing.T) {
+	scanner, err := NewLicenseScanner(0.75, "", "", "")
+	if err != nil {
+		t.Fatalf("NewLicenseScanner: %v", err)
+	}
+
+	status, _, err := scanner.Status(testCompatibility, "**", "Apache-2.0", "MIT", false)
+	if err != nil || status != "c" {
+		t.Fatalf("Status = %q, %v, want c, nil", status, err)
+	}
+
+	// A compatibility matrix that would answer differently, to prove the
+	// second call is served from compatCache rather than re-evaluated.
+	stale := Compatibility{"Apache-2.0": {"MIT": LicenseStatus{Status: "i"}}}
+	status, _, err = scanner.Status(stale, "**", "Apache-2.0", "MIT", false)
+	if err != nil || status != "c" {
+		t.Errorf("Status (should hit cache) = %q, %v, want c, nil", status, err)
+	}
+}
+
+func TestLicenseScannerSaveAndReload(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	scanner, err := NewLicenseScanner(0.75, "", cacheDir, "fingerprint-a")
+	if err != nil {
+		t.Fatalf("NewLicenseScanner: %v", err)
+	}
+	if _, _, err := scanner.Status(testCompatibility, "**", "Apache-2.0", "MIT", false); err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if err := scanner.Save(cacheDir); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded, err := NewLicenseScanner(0.75, "", cacheDir, "fingerprint-a")
+	if err != nil {
+		t.Fatalf("NewLicenseScanner (reload): %v", err)
+	}
+	if _, ok := reloaded.compatCache.Load("**|Apache-2.0|MIT"); !ok {
+		t.Error("reloaded LicenseScanner did not seed compatCache from the persisted file")
+	}
+}
+
+func TestLicenseScannerDiscardsCacheOnFingerprintMismatch(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	scanner, err := NewLicenseScanner(0.75, "", cacheDir, "fingerprint-a")
+	if err != nil {
+		t.Fatalf("NewLicenseScanner: %v", err)
+	}
+	if _, _, err := scanner.Status(testCompatibility, "**", "Apache-2.0", "MIT", false); err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if err := scanner.Save(cacheDir); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded, err := NewLicenseScanner(0.75, "", cacheDir, "fingerprint-b")
+	if err != nil {
+		t.Fatalf("NewLicenseScanner (reload): %v", err)
+	}
+	if _, ok := reloaded.compatCache.Load("**|Apache-2.0|MIT"); ok {
+		t.Error("LicenseScanner seeded compatCache from a cache persisted under a different compatibility.yaml")
+	}
+}
+
+func TestHashFileDiffersOnContentChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compatibility.yaml")
+	if err := os.WriteFile(path, []byte("a: 1"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	first, err := hashFile(path)
+	if err != nil {
+		t.Fatalf("hashFile: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("a: 2"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	second, err := hashFile(path)
+	if err != nil {
+		t.Fatalf("hashFile: %v", err)
+	}
+
+	if first == second {
+		t.Error("hashFile returned the same fingerprint for different file contents")
+	}
+}
+
+// TestProcessComponentConcurrentUse runs processComponent from many
+// goroutines against one shared LicenseScanner, the same access pattern
+// main's worker pool uses, to guard against data races in the scanner's
+// caches.
+func TestProcessComponentConcurrentUse(t *testing.T) {
+	scanner, err := NewLicenseScanner(0.75, "", "", "")
+	if err != nil {
+		t.Fatalf("NewLicenseScanner: %v", err)
+	}
+	policies := []Policy{{Root: "**", Compatibility: testCompatibility}}
+
+	components := make([]Component, 50)
+	for i := range components {
+		components[i] = Component{Name: "dep", Version: "1.0.0", Licenses: []ComponentLicense{NewConcreteLicense("MIT")}}
+	}
+
+	results := make([]workerOutput, len(components))
+	var wg sync.WaitGroup
+	for i, c := range components {
+		wg.Add(1)
+		go func(i int, c Component) {
+			defer wg.Done()
+			results[i] = processComponent(c, scanner, policies, "Apache-2.0", true)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for i, r := range results {
+		if r.result.Status != "c" {
+			t.Errorf("results[%d].Status = %q, want c", i, r.result.Status)
+		}
+	}
+}
+
+func TestProcessComponentExcluded(t *testing.T) {
+	scanner, err := NewLicenseScanner(0.75, "", "", "")
+	if err != nil {
+		t.Fatalf("NewLicenseScanner: %v", err)
+	}
+	policies := []Policy{{
+		Root:          "**",
+		Compatibility: testCompatibility,
+		Excludes:      []ComponentExclude{{Name: "left-pad"}},
+	}}
+
+	c := Component{Name: "left-pad", Version: "1.0.0", Licenses: []ComponentLicense{NewConcreteLicense("GPL-3.0")}}
+	out := processComponent(c, scanner, policies, "Apache-2.0", true)
+
+	if out.result.Status != "excluded" {
+		t.Errorf("Status = %q, want excluded", out.result.Status)
+	}
+	if out.excludedNote == "" {
+		t.Error("excludedNote is empty, want a note naming the excluded component")
+	}
+	if len(out.issues) != 0 {
+		t.Errorf("issues = %v, want none for an excluded component", out.issues)
+	}
+}